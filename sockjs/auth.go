@@ -0,0 +1,61 @@
+package sockjs
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+)
+
+// AuthFailedStatus and AuthFailedReason are the default close code and
+// reason for a session whose authentication handshake fails or errors
+// out. An Authenticator or Verifier can override them on a per-session
+// basis by implementing AuthFailureCoder.
+const (
+	AuthFailedStatus = 3000
+	AuthFailedReason = "auth failed"
+)
+
+// AuthFailureCoder is an optional capability an Authenticator or Verifier
+// may implement to override the status/reason a session is closed with on
+// handshake failure, instead of the AuthFailedStatus/AuthFailedReason
+// defaults.
+type AuthFailureCoder interface {
+	AuthFailureCode() (status uint32, reason string)
+}
+
+// Authenticator, when configured on a handler, requires an app-level
+// challenge/response exchange to complete before a session's business
+// messages are allowed through Send/Recv. Challenge is invoked once, right
+// after the "o" open frame is sent to a newly attached receiver.
+type Authenticator interface {
+	Challenge(req *http.Request) (challenge []byte, verifier Verifier, err error)
+}
+
+// Verifier processes successive rounds of a SASL-style challenge/response
+// exchange. Verify is called once per frame received while the session is
+// in the authenticating state; it returns the next challenge to send
+// (done == false) or signals that the handshake is complete
+// (done == true). A non-nil err fails the handshake and closes the
+// session, by default with AuthFailedStatus/AuthFailedReason (see
+// AuthFailureCoder to override).
+type Verifier interface {
+	Verify(response []byte) (challenge []byte, done bool, err error)
+}
+
+// authFailureCode resolves the status/reason a session should be closed
+// with on handshake failure: v's own code if it implements
+// AuthFailureCoder, otherwise the package defaults.
+func authFailureCode(v interface{}) (uint32, string) {
+	if coder, ok := v.(AuthFailureCoder); ok {
+		return coder.AuthFailureCode()
+	}
+	return AuthFailedStatus, AuthFailedReason
+}
+
+// authChallengeFrame frames challenge as a "c-auth" message, distinct from
+// the "a" data frame used for application messages, so the client's
+// transport layer can route it to the auth exchange instead of Recv.
+func authChallengeFrame(challenge []byte) string {
+	payload, _ := json.Marshal([]string{base64.StdEncoding.EncodeToString(challenge)})
+	return "c-auth" + string(payload)
+}
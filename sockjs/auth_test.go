@@ -0,0 +1,32 @@
+package sockjs
+
+import "testing"
+
+type fixedFailureCode struct {
+	status uint32
+	reason string
+}
+
+func (f fixedFailureCode) AuthFailureCode() (uint32, string) { return f.status, f.reason }
+
+func TestAuthFailureCodeDefaults(t *testing.T) {
+	status, reason := authFailureCode(struct{}{})
+	if status != AuthFailedStatus || reason != AuthFailedReason {
+		t.Fatalf("authFailureCode = (%d, %q), want (%d, %q)", status, reason, AuthFailedStatus, AuthFailedReason)
+	}
+}
+
+func TestAuthFailureCodeOverride(t *testing.T) {
+	status, reason := authFailureCode(fixedFailureCode{status: 4001, reason: "nope"})
+	if status != 4001 || reason != "nope" {
+		t.Fatalf("authFailureCode = (%d, %q), want (4001, \"nope\")", status, reason)
+	}
+}
+
+func TestAuthChallengeFrameFraming(t *testing.T) {
+	frame := authChallengeFrame([]byte("abc"))
+	want := `c-auth["YWJj"]`
+	if frame != want {
+		t.Fatalf("authChallengeFrame = %q, want %q", frame, want)
+	}
+}
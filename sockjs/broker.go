@@ -0,0 +1,46 @@
+package sockjs
+
+import "errors"
+
+// ErrSessionOwnedElsewhere is returned by SessionBroker.Claim when another
+// node already owns the session.
+var ErrSessionOwnedElsewhere = errors.New("sockjs: session owned by another node")
+
+// SessionBroker lets long-polling transports (xhr, xhr_streaming,
+// eventsource, htmlfile) hand off session ownership and frame delivery
+// across a fleet of nodes sitting behind a plain L4/L7 load balancer,
+// rather than requiring the client to keep hitting the node that holds
+// the in-memory *session.
+type SessionBroker interface {
+	// Publish broadcasts frame to whichever node currently holds a
+	// subscription for sessionID.
+	Publish(sessionID string, frame []byte) error
+	// Subscribe returns a channel of frames published for sessionID and an
+	// unsubscribe function the caller must invoke once done. The channel is
+	// closed after unsubscribe runs or the broker is closed.
+	Subscribe(sessionID string) (<-chan []byte, func(), error)
+	// Claim attempts to take ownership of sessionID for this node, so that
+	// only one node at a time proxies frames for it. It returns the id of
+	// the owning node (which may be the caller's own id if the claim
+	// succeeded) or ErrSessionOwnedElsewhere if another node holds a live
+	// claim.
+	Claim(sessionID string) (owner string, err error)
+}
+
+// Releaser is an optional capability of a SessionBroker. Implementations
+// that support it free the ownership claim made by Claim, so another node
+// can pick the session up immediately instead of waiting out however the
+// broker expires a stale claim (e.g. a JetStream key's TTL).
+type Releaser interface {
+	Release(sessionID string) error
+}
+
+// brokerInboundTopic and brokerOutboundTopic derive the two disjoint
+// Publish/Subscribe keys used to relay one session's frames over a
+// SessionBroker: inbound carries client frames from a proxying node to the
+// owner, outbound carries the owner's replies back. Keeping them distinct
+// is what lets the owner's relay loop and a proxying node's
+// routeForeignSession share a SessionBroker without a publisher ever
+// receiving its own message back off the topic it is subscribed to.
+func brokerInboundTopic(sessionID string) string  { return sessionID + ".c2s" }
+func brokerOutboundTopic(sessionID string) string { return sessionID + ".s2c" }
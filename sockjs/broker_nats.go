@@ -0,0 +1,116 @@
+package sockjs
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+)
+
+// natsBroker is a SessionBroker backed by NATS core pub/sub for frame
+// fan-out and a JetStream key-value bucket for session ownership claims.
+// Ownership keys carry a TTL (configured on the bucket) so a node that
+// dies without releasing its claim is automatically reaped.
+type natsBroker struct {
+	nc     *nats.Conn
+	kv     nats.KeyValue
+	nodeID string
+
+	mu   sync.Mutex
+	subs map[string]*nats.Subscription
+}
+
+// NewNATSSessionBroker builds a SessionBroker that fans frames out over
+// NATS subject "sockjs.session.<id>.frames" and tracks ownership in the
+// given JetStream key-value bucket, keyed by session id. nodeID identifies
+// this process and is stored as the value of a successful claim.
+func NewNATSSessionBroker(nc *nats.Conn, kv nats.KeyValue, nodeID string) SessionBroker {
+	return &natsBroker{nc: nc, kv: kv, nodeID: nodeID, subs: make(map[string]*nats.Subscription)}
+}
+
+func framesSubject(sessionID string) string {
+	return fmt.Sprintf("sockjs.session.%s.frames", sessionID)
+}
+
+func (b *natsBroker) Publish(sessionID string, frame []byte) error {
+	return b.nc.Publish(framesSubject(sessionID), frame)
+}
+
+// subscription guards the channel handed back by Subscribe so the async
+// NATS callback and unsubscribe can't race: the callback checks closed
+// and sends under the same lock unsubscribe uses to flip closed before
+// closing the channel, so a callback invocation still in flight when
+// Unsubscribe returns can never send on a closed channel.
+type subscription struct {
+	mu     sync.Mutex
+	closed bool
+	ch     chan []byte
+}
+
+func (b *natsBroker) Subscribe(sessionID string) (<-chan []byte, func(), error) {
+	s := &subscription{ch: make(chan []byte, 64)}
+	sub, err := b.nc.Subscribe(framesSubject(sessionID), func(msg *nats.Msg) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.closed {
+			return
+		}
+		select {
+		case s.ch <- msg.Data:
+		default:
+			// slow subscriber: drop rather than block the NATS dispatcher
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b.mu.Lock()
+	b.subs[sessionID] = sub
+	b.mu.Unlock()
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		delete(b.subs, sessionID)
+		b.mu.Unlock()
+
+		sub.Unsubscribe()
+
+		s.mu.Lock()
+		s.closed = true
+		s.mu.Unlock()
+		close(s.ch)
+	}
+	return s.ch, unsubscribe, nil
+}
+
+func (b *natsBroker) Claim(sessionID string) (string, error) {
+	_, err := b.kv.Create(sessionID, []byte(b.nodeID))
+	if err == nil {
+		return b.nodeID, nil
+	}
+
+	entry, getErr := b.kv.Get(sessionID)
+	if getErr != nil {
+		return "", err
+	}
+	owner := string(entry.Value())
+	if owner == b.nodeID {
+		return b.nodeID, nil
+	}
+	return owner, ErrSessionOwnedElsewhere
+}
+
+// Release implements Releaser by deleting this node's ownership claim, so
+// another node's Claim can succeed immediately instead of waiting for the
+// key-value bucket's TTL to reap it.
+func (b *natsBroker) Release(sessionID string) error {
+	entry, err := b.kv.Get(sessionID)
+	if err != nil {
+		return nil // nothing to release
+	}
+	if string(entry.Value()) != b.nodeID {
+		return nil // owned elsewhere; not ours to release
+	}
+	return b.kv.Delete(sessionID)
+}
@@ -0,0 +1,145 @@
+package sockjs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newHTTPRequest() *http.Request {
+	return httptest.NewRequest(http.MethodGet, "/", nil)
+}
+
+func TestBrokerTopicsAreDisjoint(t *testing.T) {
+	if brokerInboundTopic("sid") == brokerOutboundTopic("sid") {
+		t.Fatal("inbound and outbound topics must differ for the same session id")
+	}
+}
+
+// fakeBroker is an in-memory SessionBroker good enough to exercise the
+// relay wiring without a real NATS connection.
+type fakeBroker struct {
+	mu       sync.Mutex
+	subs     map[string]chan []byte
+	released map[string]bool
+}
+
+func newFakeBroker() *fakeBroker {
+	return &fakeBroker{subs: map[string]chan []byte{}, released: map[string]bool{}}
+}
+
+func (b *fakeBroker) Publish(topic string, frame []byte) error {
+	b.mu.Lock()
+	ch, ok := b.subs[topic]
+	b.mu.Unlock()
+	if ok {
+		select {
+		case ch <- frame:
+		default:
+		}
+	}
+	return nil
+}
+
+func (b *fakeBroker) Subscribe(topic string) (<-chan []byte, func(), error) {
+	ch := make(chan []byte, 8)
+	b.mu.Lock()
+	b.subs[topic] = ch
+	b.mu.Unlock()
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.subs, topic)
+		b.mu.Unlock()
+	}, nil
+}
+
+func (b *fakeBroker) Claim(sessionID string) (string, error) { return "node-a", nil }
+
+func (b *fakeBroker) Release(sessionID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.released[sessionID] = true
+	return nil
+}
+
+func (b *fakeBroker) wasReleased(sessionID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.released[sessionID]
+}
+
+func TestSessionRelaysInboundBrokerFrames(t *testing.T) {
+	broker := newFakeBroker()
+	s := newSession(newHTTPRequest(), "sid", time.Minute, 0, SendBufferOptions{}, nil, broker)
+	defer s.close()
+
+	// Give relayBrokerInbound time to subscribe before publishing.
+	deadline := time.After(time.Second)
+	for {
+		broker.mu.Lock()
+		_, subscribed := broker.subs[brokerInboundTopic("sid")]
+		broker.mu.Unlock()
+		if subscribed {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("relayBrokerInbound never subscribed")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	broker.Publish(brokerInboundTopic("sid"), []byte("from-proxy"))
+
+	msg, err := s.RecvContext(context.Background())
+	if err != nil {
+		t.Fatalf("RecvContext: %v", err)
+	}
+	if msg != "from-proxy" {
+		t.Fatalf("RecvContext = %q, want %q", msg, "from-proxy")
+	}
+}
+
+func TestSessionPublishesOutboundBrokerFrames(t *testing.T) {
+	broker := newFakeBroker()
+	s := newSession(newHTTPRequest(), "sid2", time.Minute, 0, SendBufferOptions{}, nil, broker)
+	defer s.close()
+
+	frames, unsubscribe, err := broker.Subscribe(brokerOutboundTopic("sid2"))
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer unsubscribe()
+
+	if err := s.sendMessage("to-proxy"); err != nil {
+		t.Fatalf("sendMessage: %v", err)
+	}
+
+	select {
+	case frame := <-frames:
+		if string(frame) != "to-proxy" {
+			t.Fatalf("frame = %q, want %q", frame, "to-proxy")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("outbound frame was never published")
+	}
+}
+
+func TestSessionCloseReleasesBroker(t *testing.T) {
+	broker := newFakeBroker()
+	s := newSession(newHTTPRequest(), "sid3", time.Minute, 0, SendBufferOptions{}, nil, broker)
+
+	s.close()
+
+	deadline := time.After(time.Second)
+	for !broker.wasReleased("sid3") {
+		select {
+		case <-deadline:
+			t.Fatal("broker.Release was never called")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
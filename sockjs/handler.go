@@ -0,0 +1,144 @@
+package sockjs
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Handler owns the sessions created under one SockJS endpoint and, when
+// Broker is set, routes requests for sessions this node doesn't hold
+// in-memory to whichever node does.
+type Handler struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+
+	sendBufferOptions SendBufferOptions
+	authenticator     Authenticator
+	middlewares       []Middleware
+
+	// SockJSHandler owns each session for its lifetime. It runs once per
+	// new session, after all middleware registered via Use.
+	SockJSHandler SessionHandler
+
+	// Broker, if set, lets sessions be claimed and their frames proxied
+	// across a fleet of nodes behind a plain L4/L7 load balancer instead
+	// of requiring the client to keep hitting the node that holds the
+	// in-memory *session.
+	Broker SessionBroker
+
+	// NodeAddr is this node's externally reachable base URL (e.g.
+	// "https://node-a.internal:8080"). When proxying over Broker fails,
+	// the redirect Location is built from the owner recorded by Claim;
+	// an owner equal to NodeAddr gets a 500 instead of a redirect back
+	// to this node.
+	NodeAddr string
+}
+
+// NewHandler returns a Handler with no sessions and no Broker configured.
+// Set SockJSHandler before serving requests.
+func NewHandler() *Handler {
+	return &Handler{sessions: make(map[string]*session)}
+}
+
+// Use registers mw to run once per new session, before SockJSHandler.
+// Middlewares run in registration order: the first Use call is outermost
+// and observes the session first.
+func (h *Handler) Use(mw Middleware) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.middlewares = append(h.middlewares, mw)
+}
+
+// session returns the locally held session for id, if any.
+func (h *Handler) session(id string) (*session, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.sessions[id]
+	return s, ok
+}
+
+// createSession creates and registers a new local session for id, claiming
+// ownership of it over Broker (if configured), then runs the registered
+// middleware chain and SockJSHandler over it for its lifetime.
+func (h *Handler) createSession(req *http.Request, id string) *session {
+	s := newSession(req, id, 25*1000, 0, h.sendBufferOptions, h.authenticator, h.Broker)
+	h.mu.Lock()
+	h.sessions[id] = s
+	mws := h.middlewares
+	handle := h.SockJSHandler
+	h.mu.Unlock()
+	go func() {
+		<-s.closedNotify()
+		h.mu.Lock()
+		delete(h.sessions, id)
+		h.mu.Unlock()
+	}()
+	if handle != nil {
+		go chain(handle, mws)(s)
+	}
+	return s
+}
+
+// routeForeignSession handles a request for sessionID when this node does
+// not hold it locally: it proxies the request body over Broker and
+// writes back the first frame the owner publishes in reply, falling back
+// to an HTTP 307 redirect to the owner recorded by Claim when subscribing
+// or publishing over Broker fails. It reports whether it fully handled
+// the request (true) or the caller should create a local session and
+// proceed as the owner (false); the latter when no Broker is configured
+// or this node's Claim succeeds.
+func (h *Handler) routeForeignSession(w http.ResponseWriter, r *http.Request, sessionID string) bool {
+	if h.Broker == nil {
+		return false
+	}
+
+	owner, err := h.Broker.Claim(sessionID)
+	if err == nil {
+		return false // we now own it locally; caller proceeds as usual
+	}
+	if err != ErrSessionOwnedElsewhere {
+		http.Error(w, "sockjs: "+err.Error(), http.StatusInternalServerError)
+		return true
+	}
+
+	// Subscribe on the owner's outbound topic and publish on the inbound
+	// one: using the same subject for both, as this used to, meant a
+	// proxying node received its own just-published frame straight back
+	// off its own subscription instead of the owner's reply.
+	frames, unsubscribe, subErr := h.Broker.Subscribe(brokerOutboundTopic(sessionID))
+	if subErr != nil {
+		h.redirectToOwner(w, r, owner)
+		return true
+	}
+	defer unsubscribe()
+
+	body, readErr := io.ReadAll(r.Body)
+	if readErr != nil {
+		http.Error(w, "sockjs: "+readErr.Error(), http.StatusBadRequest)
+		return true
+	}
+	if pubErr := h.Broker.Publish(brokerInboundTopic(sessionID), body); pubErr != nil {
+		h.redirectToOwner(w, r, owner)
+		return true
+	}
+
+	select {
+	case frame, ok := <-frames:
+		if !ok {
+			http.Error(w, "sockjs: broker subscription closed", http.StatusBadGateway)
+			return true
+		}
+		w.Write(frame)
+	case <-r.Context().Done():
+	}
+	return true
+}
+
+func (h *Handler) redirectToOwner(w http.ResponseWriter, r *http.Request, owner string) {
+	if owner == "" || owner == h.NodeAddr {
+		http.Error(w, "sockjs: session owner unknown", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, owner+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+}
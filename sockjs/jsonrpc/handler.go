@@ -0,0 +1,39 @@
+package jsonrpc
+
+import "encoding/json"
+
+// Request is an inbound call or notification delivered to a Handler. ID
+// reports whether it is a call (and if so, which ID to Reply with) or a
+// notification.
+type Request struct {
+	Method string
+	Params json.RawMessage
+
+	id *string
+}
+
+// ID returns the request's ID and true if it is a call, or the zero ID and
+// false if it is a notification.
+func (r *Request) ID() (ID, bool) {
+	if r.id == nil {
+		return ID{}, false
+	}
+	return ID{value: *r.id}, true
+}
+
+// Handler processes inbound requests and notifications delivered over a
+// Conn. Handle is invoked once per request from a pool of defaultWorkers
+// goroutines, so up to that many calls may block at once without stalling
+// the read loop; once every worker is busy, the read loop waits for one to
+// free up before it reads further messages. Handle must call conn.Reply
+// for every request where r.ID() reports ok == true, and must not call
+// conn.Reply for notifications.
+type Handler interface {
+	Handle(conn *Conn, r *Request)
+}
+
+// HandlerFunc adapts a function to a Handler.
+type HandlerFunc func(conn *Conn, r *Request)
+
+// Handle implements Handler.
+func (f HandlerFunc) Handle(conn *Conn, r *Request) { f(conn, r) }
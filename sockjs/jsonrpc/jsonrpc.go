@@ -0,0 +1,281 @@
+// Package jsonrpc layers bidirectional JSON-RPC 2.0 (calls, notifications
+// and batches) on top of a sockjs.Session, mirroring the shape of
+// golang.org/x/tools' internal jsonrpc2.Conn: a Handler processes inbound
+// requests, and Go spawns the read loop that feeds it.
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// defaultWorkers bounds the number of inbound requests dispatched to the
+// Handler concurrently.
+const defaultWorkers = 8
+
+// Session is the subset of sockjs.Session a Conn needs. *sockjs.session
+// (the value a sockjs.Handler passes to its SockJSHandler) already
+// satisfies it.
+type Session interface {
+	Send(msg string) error
+	SendContext(ctx context.Context, msg string) error
+	Recv() (string, error)
+	RecvContext(ctx context.Context) (string, error)
+	ClosedNotify() <-chan struct{}
+}
+
+// pendingCall is a call awaiting its matching response.
+type pendingCall struct {
+	resp chan *wireMessage
+}
+
+// Conn wraps a sockjs.Session with JSON-RPC 2.0 call/notify/dispatch
+// semantics. Create one with NewConn and start it with Go.
+type Conn struct {
+	session Session
+
+	lastID uint64
+
+	mu       sync.Mutex
+	pending  map[string]*pendingCall
+	closed   bool
+	closeErr error
+
+	work chan *Request
+	quit chan struct{}
+}
+
+// NewConn wraps session. The returned Conn does nothing until Go is called.
+func NewConn(session Session) *Conn {
+	return &Conn{
+		session: session,
+		pending: make(map[string]*pendingCall),
+		quit:    make(chan struct{}),
+	}
+}
+
+// Go starts the read loop and a worker pool that dispatches inbound
+// requests and notifications to handler. It returns immediately; the
+// spawned goroutines run until ctx is done or the session closes.
+func (c *Conn) Go(ctx context.Context, handler Handler) {
+	c.work = make(chan *Request)
+
+	for i := 0; i < defaultWorkers; i++ {
+		go c.worker(handler)
+	}
+
+	go func() {
+		select {
+		case <-c.session.ClosedNotify():
+			c.shutdown(errors.New("jsonrpc: session closed"))
+		case <-c.quit:
+		}
+	}()
+
+	go c.readLoop(ctx)
+}
+
+func (c *Conn) worker(handler Handler) {
+	for {
+		select {
+		case req := <-c.work:
+			handler.Handle(c, req)
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+func (c *Conn) readLoop(ctx context.Context) {
+	for {
+		msg, err := c.session.RecvContext(ctx)
+		if err != nil {
+			c.shutdown(err)
+			return
+		}
+		c.dispatch(msg)
+	}
+}
+
+func (c *Conn) dispatch(raw string) {
+	var batch []json.RawMessage
+	trimmed := []byte(raw)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &batch); err != nil {
+			return // malformed batch: nothing sensible to correlate an error to
+		}
+	} else {
+		batch = []json.RawMessage{trimmed}
+	}
+
+	for _, item := range batch {
+		var m wireMessage
+		if err := json.Unmarshal(item, &m); err != nil {
+			continue
+		}
+		c.dispatchOne(&m)
+	}
+}
+
+func (c *Conn) dispatchOne(m *wireMessage) {
+	if m.isResponse() {
+		c.deliver(m)
+		return
+	}
+
+	req := &Request{Method: m.Method, Params: m.Params, id: m.ID}
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	if closed {
+		return
+	}
+	select {
+	case c.work <- req:
+	case <-c.quit:
+	}
+}
+
+func (c *Conn) deliver(m *wireMessage) {
+	if m.ID == nil {
+		return
+	}
+	c.mu.Lock()
+	call, ok := c.pending[*m.ID]
+	if ok {
+		delete(c.pending, *m.ID)
+	}
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+	call.resp <- m
+}
+
+// Call sends method with params and blocks until a matching response
+// arrives, ctx is done, or the session closes. On success the response's
+// "result" is unmarshaled into result (which should be a pointer, as with
+// json.Unmarshal). The returned ID is the one assigned to the call.
+func (c *Conn) Call(ctx context.Context, method string, params, result interface{}) (ID, error) {
+	id := strconv.FormatUint(atomic.AddUint64(&c.lastID, 1), 10)
+
+	paramsRaw, err := marshalParams(params)
+	if err != nil {
+		return ID{}, err
+	}
+
+	call := &pendingCall{resp: make(chan *wireMessage, 1)}
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ID{}, c.closeErr
+	}
+	c.pending[id] = call
+	c.mu.Unlock()
+
+	m := wireMessage{JSONRPC: protocolVersion, ID: &id, Method: method, Params: paramsRaw}
+	payload, err := json.Marshal(m)
+	if err != nil {
+		c.forgetPending(id)
+		return ID{}, err
+	}
+	if err := c.session.SendContext(ctx, string(payload)); err != nil {
+		c.forgetPending(id)
+		return ID{}, err
+	}
+
+	select {
+	case resp := <-call.resp:
+		if resp.Error != nil {
+			return ID{value: id}, resp.Error
+		}
+		if result != nil && len(resp.Result) > 0 {
+			if err := json.Unmarshal(resp.Result, result); err != nil {
+				return ID{value: id}, err
+			}
+		}
+		return ID{value: id}, nil
+	case <-ctx.Done():
+		c.forgetPending(id)
+		return ID{value: id}, ctx.Err()
+	}
+}
+
+func (c *Conn) forgetPending(id string) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+// Notify sends method with params without expecting a response.
+func (c *Conn) Notify(ctx context.Context, method string, params interface{}) error {
+	paramsRaw, err := marshalParams(params)
+	if err != nil {
+		return err
+	}
+	m := wireMessage{JSONRPC: protocolVersion, Method: method, Params: paramsRaw}
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return c.session.SendContext(ctx, string(payload))
+}
+
+// Reply sends the result of handling a call whose Request.ID() reported
+// ok == true. err, if non-nil, is marshaled as a JSON-RPC error object
+// (wrapping it in an *Error first if it is not already one).
+func (c *Conn) Reply(ctx context.Context, id ID, result interface{}, err error) error {
+	m := wireMessage{JSONRPC: protocolVersion, ID: &id.value}
+	if err != nil {
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &Error{Code: CodeInternalError, Message: err.Error()}
+		}
+		m.Error = rpcErr
+	} else if result != nil {
+		raw, marshalErr := json.Marshal(result)
+		if marshalErr != nil {
+			return marshalErr
+		}
+		m.Result = raw
+	}
+	payload, marshalErr := json.Marshal(m)
+	if marshalErr != nil {
+		return marshalErr
+	}
+	return c.session.SendContext(ctx, string(payload))
+}
+
+func (c *Conn) shutdown(err error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.closed = true
+	c.closeErr = err
+	pending := c.pending
+	c.pending = nil
+	c.mu.Unlock()
+
+	for _, call := range pending {
+		call.resp <- &wireMessage{Error: &Error{Code: CodeInternalError, Message: err.Error()}}
+	}
+	close(c.quit)
+}
+
+func marshalParams(params interface{}) (json.RawMessage, error) {
+	if params == nil {
+		return nil, nil
+	}
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("jsonrpc: marshal params: %w", err)
+	}
+	return raw, nil
+}
@@ -0,0 +1,253 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSession is the minimal Session a Conn needs, backed by an in-memory
+// queue instead of a real sockjs.Session.
+type fakeSession struct {
+	mu     sync.Mutex
+	sent   []string
+	recvCh chan string
+	closed chan struct{}
+}
+
+func newFakeSession() *fakeSession {
+	return &fakeSession{recvCh: make(chan string, 8), closed: make(chan struct{})}
+}
+
+func (s *fakeSession) Send(msg string) error { return s.SendContext(context.Background(), msg) }
+
+func (s *fakeSession) SendContext(ctx context.Context, msg string) error {
+	s.mu.Lock()
+	s.sent = append(s.sent, msg)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *fakeSession) Recv() (string, error) { return s.RecvContext(context.Background()) }
+
+func (s *fakeSession) RecvContext(ctx context.Context) (string, error) {
+	select {
+	case msg := <-s.recvCh:
+		return msg, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-s.closed:
+		return "", errors.New("jsonrpc: fakeSession closed")
+	}
+}
+
+func (s *fakeSession) ClosedNotify() <-chan struct{} { return s.closed }
+
+func (s *fakeSession) lastSent() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.sent) == 0 {
+		return ""
+	}
+	return s.sent[len(s.sent)-1]
+}
+
+func TestConnCallCancelledByContext(t *testing.T) {
+	sess := newFakeSession()
+	conn := NewConn(sess)
+	conn.Go(context.Background(), HandlerFunc(func(c *Conn, r *Request) {}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := conn.Call(ctx, "method", nil, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Call = %v, want context.DeadlineExceeded", err)
+	}
+
+	conn.mu.Lock()
+	pending := len(conn.pending)
+	conn.mu.Unlock()
+	if pending != 0 {
+		t.Fatalf("pending calls = %d, want 0 (forgetPending should have run)", pending)
+	}
+}
+
+func TestConnCallReceivesResult(t *testing.T) {
+	sess := newFakeSession()
+	conn := NewConn(sess)
+	conn.Go(context.Background(), HandlerFunc(func(c *Conn, r *Request) {}))
+
+	go func() {
+		// Wait for the call to be sent so we know its assigned ID before
+		// replying to it.
+		deadline := time.After(time.Second)
+		for sess.lastSent() == "" {
+			select {
+			case <-deadline:
+				return
+			case <-time.After(time.Millisecond):
+			}
+		}
+		var sent wireMessage
+		if err := json.Unmarshal([]byte(sess.lastSent()), &sent); err != nil {
+			t.Errorf("unmarshal sent message: %v", err)
+			return
+		}
+		resp := wireMessage{JSONRPC: protocolVersion, ID: sent.ID, Result: json.RawMessage(`42`)}
+		raw, _ := json.Marshal(resp)
+		sess.recvCh <- string(raw)
+	}()
+
+	var result int
+	if _, err := conn.Call(context.Background(), "add", nil, &result); err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if result != 42 {
+		t.Fatalf("result = %d, want 42", result)
+	}
+}
+
+func TestConnCallReceivesError(t *testing.T) {
+	sess := newFakeSession()
+	conn := NewConn(sess)
+	conn.Go(context.Background(), HandlerFunc(func(c *Conn, r *Request) {}))
+
+	go func() {
+		deadline := time.After(time.Second)
+		for sess.lastSent() == "" {
+			select {
+			case <-deadline:
+				return
+			case <-time.After(time.Millisecond):
+			}
+		}
+		var sent wireMessage
+		json.Unmarshal([]byte(sess.lastSent()), &sent)
+		resp := wireMessage{JSONRPC: protocolVersion, ID: sent.ID, Error: &Error{Code: CodeInvalidParams, Message: "nope"}}
+		raw, _ := json.Marshal(resp)
+		sess.recvCh <- string(raw)
+	}()
+
+	_, err := conn.Call(context.Background(), "add", nil, nil)
+	var rpcErr *Error
+	if !errors.As(err, &rpcErr) || rpcErr.Code != CodeInvalidParams {
+		t.Fatalf("Call err = %v, want *Error{Code: CodeInvalidParams}", err)
+	}
+}
+
+func TestConnDispatchesRequestAndReplies(t *testing.T) {
+	sess := newFakeSession()
+	conn := NewConn(sess)
+
+	handled := make(chan *Request, 1)
+	conn.Go(context.Background(), HandlerFunc(func(c *Conn, r *Request) {
+		handled <- r
+		id, ok := r.ID()
+		if !ok {
+			t.Error("expected a call, got a notification")
+			return
+		}
+		c.Reply(context.Background(), id, "pong", nil)
+	}))
+
+	sess.recvCh <- `{"jsonrpc":"2.0","id":"7","method":"ping"}`
+
+	select {
+	case r := <-handled:
+		if r.Method != "ping" {
+			t.Fatalf("Method = %q, want ping", r.Method)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler was never invoked")
+	}
+
+	deadline := time.After(time.Second)
+	for sess.lastSent() == "" {
+		select {
+		case <-deadline:
+			t.Fatal("Reply was never sent")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	var reply wireMessage
+	if err := json.Unmarshal([]byte(sess.lastSent()), &reply); err != nil {
+		t.Fatalf("unmarshal reply: %v", err)
+	}
+	if reply.ID == nil || *reply.ID != "7" {
+		t.Fatalf("reply ID = %v, want 7", reply.ID)
+	}
+}
+
+func TestConnDispatchesBatch(t *testing.T) {
+	sess := newFakeSession()
+	conn := NewConn(sess)
+
+	var mu sync.Mutex
+	var methods []string
+	conn.Go(context.Background(), HandlerFunc(func(c *Conn, r *Request) {
+		mu.Lock()
+		methods = append(methods, r.Method)
+		mu.Unlock()
+	}))
+
+	sess.recvCh <- `[{"jsonrpc":"2.0","method":"a"},{"jsonrpc":"2.0","method":"b"}]`
+
+	deadline := time.After(time.Second)
+	for {
+		mu.Lock()
+		n := len(methods)
+		mu.Unlock()
+		if n == 2 {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("only %d of 2 batch entries dispatched", n)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestConnNotifySendsNoID(t *testing.T) {
+	sess := newFakeSession()
+	conn := NewConn(sess)
+
+	if err := conn.Notify(context.Background(), "ping", nil); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	var m wireMessage
+	if err := json.Unmarshal([]byte(sess.lastSent()), &m); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if m.ID != nil {
+		t.Fatalf("Notify set an ID: %v", m.ID)
+	}
+	if m.Method != "ping" {
+		t.Fatalf("Method = %q, want ping", m.Method)
+	}
+}
+
+func TestConnCallFailsAfterSessionCloses(t *testing.T) {
+	sess := newFakeSession()
+	conn := NewConn(sess)
+	conn.Go(context.Background(), HandlerFunc(func(c *Conn, r *Request) {}))
+
+	close(sess.closed)
+
+	deadline := time.After(time.Second)
+	for {
+		_, err := conn.Call(context.Background(), "method", nil, nil)
+		if err != nil {
+			return
+		}
+		select {
+		case <-deadline:
+			t.Fatal("Call kept succeeding after the session closed")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
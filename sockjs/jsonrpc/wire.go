@@ -0,0 +1,69 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const protocolVersion = "2.0"
+
+// Standard JSON-RPC 2.0 error codes.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// ID identifies a call and its matching response. The zero ID is never
+// assigned by Conn.Call.
+type ID struct {
+	value string
+}
+
+func (id ID) String() string { return id.value }
+
+// Error is a JSON-RPC 2.0 error object, returned by Call when the peer
+// replies with "error" instead of "result".
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonrpc: %s (code %d)", e.Message, e.Code)
+}
+
+// NewError builds an *Error carrying data, marshaling data to JSON. It
+// panics if data cannot be marshaled, mirroring encoding/json's own
+// behaviour for programmer errors.
+func NewError(code int, message string, data interface{}) *Error {
+	err := &Error{Code: code, Message: message}
+	if data != nil {
+		raw, marshalErr := json.Marshal(data)
+		if marshalErr != nil {
+			panic("jsonrpc: NewError: " + marshalErr.Error())
+		}
+		err.Data = raw
+	}
+	return err
+}
+
+// wireMessage is the on-the-wire shape of a request, notification or
+// response. Method disambiguates the three: requests and notifications
+// always set it, responses never do (ID nil means notification; Result
+// and Error may both be absent for a successful call with a void result).
+type wireMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *string         `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+func (m *wireMessage) isResponse() bool {
+	return m.Method == "" && m.ID != nil
+}
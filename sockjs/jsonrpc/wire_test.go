@@ -0,0 +1,42 @@
+package jsonrpc
+
+import "testing"
+
+func TestIsResponse(t *testing.T) {
+	id := "5"
+	cases := []struct {
+		name string
+		m    wireMessage
+		want bool
+	}{
+		{"request", wireMessage{Method: "do", ID: &id}, false},
+		{"notification", wireMessage{Method: "do"}, false},
+		{"response with result", wireMessage{ID: &id, Result: []byte(`1`)}, true},
+		{"response with error", wireMessage{ID: &id, Error: &Error{Code: 1}}, true},
+		{"response with void result", wireMessage{ID: &id}, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.m.isResponse(); got != c.want {
+				t.Fatalf("isResponse() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestNewErrorMarshalsData(t *testing.T) {
+	err := NewError(CodeInvalidParams, "bad params", map[string]int{"n": 1})
+	if err.Code != CodeInvalidParams {
+		t.Fatalf("Code = %d, want %d", err.Code, CodeInvalidParams)
+	}
+	if string(err.Data) != `{"n":1}` {
+		t.Fatalf("Data = %s, want %s", err.Data, `{"n":1}`)
+	}
+}
+
+func TestNewErrorNilData(t *testing.T) {
+	err := NewError(CodeInternalError, "boom", nil)
+	if err.Data != nil {
+		t.Fatalf("Data = %s, want nil", err.Data)
+	}
+}
@@ -0,0 +1,87 @@
+package sockjs
+
+import (
+	"context"
+	"sync"
+)
+
+// messageBuffer is a thread-safe FIFO queue of messages received from the
+// client and waiting to be consumed by the application via Session.Recv.
+type messageBuffer struct {
+	mu     sync.Mutex
+	queue  []string
+	closed bool
+	notify chan struct{}
+}
+
+func newMessageBuffer() *messageBuffer {
+	return &messageBuffer{notify: make(chan struct{})}
+}
+
+func (b *messageBuffer) push(messages ...string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return ErrSessionNotOpen
+	}
+	b.queue = append(b.queue, messages...)
+	close(b.notify)
+	b.notify = make(chan struct{})
+	return nil
+}
+
+// pop removes and returns the oldest queued message, blocking until one is
+// available or the buffer is closed.
+func (b *messageBuffer) pop() (string, error) {
+	for {
+		b.mu.Lock()
+		if len(b.queue) > 0 {
+			msg := b.queue[0]
+			b.queue = b.queue[1:]
+			b.mu.Unlock()
+			return msg, nil
+		}
+		if b.closed {
+			b.mu.Unlock()
+			return "", ErrSessionNotOpen
+		}
+		wait := b.notify
+		b.mu.Unlock()
+		<-wait
+	}
+}
+
+// popContext behaves like pop but returns ctx.Err() if ctx is cancelled
+// before a message becomes available.
+func (b *messageBuffer) popContext(ctx context.Context) (string, error) {
+	for {
+		b.mu.Lock()
+		if len(b.queue) > 0 {
+			msg := b.queue[0]
+			b.queue = b.queue[1:]
+			b.mu.Unlock()
+			return msg, nil
+		}
+		if b.closed {
+			b.mu.Unlock()
+			return "", ErrSessionNotOpen
+		}
+		wait := b.notify
+		b.mu.Unlock()
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+func (b *messageBuffer) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	close(b.notify)
+}
@@ -0,0 +1,86 @@
+package sockjs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMessageBufferPushPop(t *testing.T) {
+	b := newMessageBuffer()
+	if err := b.push("a", "b"); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	for _, want := range []string{"a", "b"} {
+		got, err := b.pop()
+		if err != nil {
+			t.Fatalf("pop: %v", err)
+		}
+		if got != want {
+			t.Fatalf("pop = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestMessageBufferPopBlocksUntilPush(t *testing.T) {
+	b := newMessageBuffer()
+	done := make(chan string, 1)
+	go func() {
+		msg, err := b.pop()
+		if err != nil {
+			t.Errorf("pop: %v", err)
+			return
+		}
+		done <- msg
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("pop returned before push")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	b.push("late")
+	select {
+	case msg := <-done:
+		if msg != "late" {
+			t.Fatalf("pop = %q, want %q", msg, "late")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("pop never returned after push")
+	}
+}
+
+func TestMessageBufferPopContextCancelled(t *testing.T) {
+	b := newMessageBuffer()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := b.popContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("popContext = %v, want context.Canceled", err)
+	}
+}
+
+func TestMessageBufferPopContextCancelledWhileWaiting(t *testing.T) {
+	b := newMessageBuffer()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := b.popContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("popContext = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestMessageBufferPopAfterClose(t *testing.T) {
+	b := newMessageBuffer()
+	b.close()
+
+	if _, err := b.pop(); err != ErrSessionNotOpen {
+		t.Fatalf("pop after close = %v, want ErrSessionNotOpen", err)
+	}
+	if err := b.push("x"); err != ErrSessionNotOpen {
+		t.Fatalf("push after close = %v, want ErrSessionNotOpen", err)
+	}
+}
@@ -0,0 +1,22 @@
+package sockjs
+
+// SessionHandler owns a session for its lifetime, as Handler.SockJSHandler
+// does once middleware has run.
+type SessionHandler func(Session)
+
+// Middleware wraps a SessionHandler with behaviour that should run once
+// per new session, before the user's SockJSHandler — typically attaching
+// auth principals, tenant IDs, tracing spans or rate-limit tokens via
+// Session.WithValue. Middlewares are registered with Handler.Use and
+// composed with chain when the Handler creates a session.
+type Middleware func(next SessionHandler) SessionHandler
+
+// chain composes mws around base in registration order, so the first
+// middleware passed to Use is outermost and observes the session first.
+func chain(base SessionHandler, mws []Middleware) SessionHandler {
+	h := base
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
@@ -0,0 +1,76 @@
+package sockjs
+
+import "testing"
+
+func TestChainRunsMiddlewareInRegistrationOrder(t *testing.T) {
+	var order []string
+	record := func(name string) Middleware {
+		return func(next SessionHandler) SessionHandler {
+			return func(s Session) {
+				order = append(order, name)
+				next(s)
+			}
+		}
+	}
+
+	base := SessionHandler(func(s Session) { order = append(order, "base") })
+	handler := chain(base, []Middleware{record("first"), record("second")})
+	handler(newTestSession(t))
+
+	want := []string{"first", "second", "base"}
+	if len(order) != len(want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+	for i, name := range want {
+		if order[i] != name {
+			t.Fatalf("order = %v, want %v", order, want)
+		}
+	}
+}
+
+func TestChainWithNoMiddlewareRunsBase(t *testing.T) {
+	ran := false
+	base := SessionHandler(func(s Session) { ran = true })
+	chain(base, nil)(newTestSession(t))
+	if !ran {
+		t.Fatal("base handler was not invoked")
+	}
+}
+
+func TestSessionContextCancelledOnClose(t *testing.T) {
+	s := newTestSession(t)
+	ctx := s.Context()
+
+	s.close()
+
+	select {
+	case <-ctx.Done():
+	default:
+		t.Fatal("Context() was not cancelled by close()")
+	}
+}
+
+func TestSessionWithValueIsVisibleAndIsolated(t *testing.T) {
+	s := newTestSession(t)
+	type key struct{}
+
+	before := s.Context()
+	if before.Value(key{}) != nil {
+		t.Fatal("expected no value before WithValue")
+	}
+
+	s.WithValue(key{}, "tenant-1")
+	after := s.Context()
+	if after.Value(key{}) != "tenant-1" {
+		t.Fatalf("Context().Value = %v, want tenant-1", after.Value(key{}))
+	}
+
+	// The context captured before WithValue must not observe the update:
+	// WithValue replaces the stored context rather than mutating it.
+	if before.Value(key{}) != nil {
+		t.Fatal("earlier Context() snapshot observed a later WithValue")
+	}
+	if before == after {
+		t.Fatal("WithValue should store a new context, not mutate in place")
+	}
+}
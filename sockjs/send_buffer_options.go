@@ -0,0 +1,54 @@
+package sockjs
+
+// OverflowPolicy controls what sendMessage does when a session's send
+// buffer has grown past the limits configured via SendBufferOptions.
+type OverflowPolicy int
+
+const (
+	// Block makes the sender wait until buffer space is available or the
+	// caller's context is cancelled (see Conn.SendContext). This is the
+	// zero value and matches the historical unbounded-but-synchronous
+	// behaviour as closely as a bounded buffer allows.
+	Block OverflowPolicy = iota
+	// DropOldest discards buffered messages, oldest first, until the new
+	// message fits. A message that exceeds MaxBytes on its own is
+	// discarded as well, after the buffer has been emptied.
+	DropOldest
+	// DropNewest discards the incoming message, leaving the buffer as is.
+	DropNewest
+	// CloseSession closes the session instead of accepting the message.
+	CloseSession
+)
+
+// SendBufferOptions configures backpressure for a session's outbound
+// message buffer. The zero value imposes no limit, preserving the
+// historical unbounded behaviour of sendMessage.
+type SendBufferOptions struct {
+	// MaxMessages caps the number of buffered messages. Zero means no limit.
+	MaxMessages int
+	// MaxBytes caps the total size, in bytes, of buffered messages. Zero
+	// means no limit.
+	MaxBytes int
+	// OverflowPolicy decides what happens once either limit is reached.
+	OverflowPolicy OverflowPolicy
+	// OnOverflow, if set, is invoked synchronously whenever OverflowPolicy
+	// takes effect: DropOldest reports how many buffered messages it
+	// evicted, DropNewest and CloseSession always report 0 (they never
+	// remove anything already queued). Block never invokes it. Use it to
+	// wire up operator-facing metrics.
+	OnOverflow func(sessionID string, policy OverflowPolicy, dropped int)
+}
+
+func (o SendBufferOptions) limited() bool {
+	return o.MaxMessages > 0 || o.MaxBytes > 0
+}
+
+// SessionStats reports point-in-time observability data about a session's
+// outbound send buffer, see Session.Stats.
+type SessionStats struct {
+	// BufferedMessages is the number of messages currently queued in the
+	// send buffer, waiting for a receiver to attach or drain them.
+	BufferedMessages int
+	// BufferedBytes is the total size, in bytes, of those messages.
+	BufferedBytes int
+}
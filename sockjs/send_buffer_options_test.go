@@ -0,0 +1,143 @@
+package sockjs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newBufferedTestSession(t *testing.T, opts SendBufferOptions) *session {
+	t.Helper()
+	s := newTestSession(t)
+	s.sendBufferOptions = opts
+	return s
+}
+
+func TestSendBufferOptionsLimited(t *testing.T) {
+	cases := []struct {
+		name string
+		opts SendBufferOptions
+		want bool
+	}{
+		{"zero value", SendBufferOptions{}, false},
+		{"max messages", SendBufferOptions{MaxMessages: 1}, true},
+		{"max bytes", SendBufferOptions{MaxBytes: 1}, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.opts.limited(); got != c.want {
+				t.Fatalf("limited() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSendMessageContextBlockWaitsThenCancels(t *testing.T) {
+	s := newBufferedTestSession(t, SendBufferOptions{MaxMessages: 1, OverflowPolicy: Block})
+
+	if err := s.sendMessage("first"); err != nil {
+		t.Fatalf("first send: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.SendContext(ctx, "second"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("SendContext = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestSendMessageContextDropNewest(t *testing.T) {
+	var gotPolicy OverflowPolicy
+	var gotDropped int
+	s := newBufferedTestSession(t, SendBufferOptions{
+		MaxMessages:    1,
+		OverflowPolicy: DropNewest,
+		OnOverflow: func(sessionID string, policy OverflowPolicy, dropped int) {
+			gotPolicy, gotDropped = policy, dropped
+		},
+	})
+
+	if err := s.sendMessage("first"); err != nil {
+		t.Fatalf("first send: %v", err)
+	}
+	if err := s.sendMessage("second"); err != nil {
+		t.Fatalf("second send: %v", err)
+	}
+	if len(s.sendBuffer) != 1 || s.sendBuffer[0] != "first" {
+		t.Fatalf("sendBuffer = %v, want [first]", s.sendBuffer)
+	}
+	if gotPolicy != DropNewest || gotDropped != 0 {
+		t.Fatalf("OnOverflow(policy=%v, dropped=%d), want (DropNewest, 0)", gotPolicy, gotDropped)
+	}
+}
+
+func TestSendMessageContextDropOldestEvicts(t *testing.T) {
+	s := newBufferedTestSession(t, SendBufferOptions{MaxMessages: 1, OverflowPolicy: DropOldest})
+
+	if err := s.sendMessage("first"); err != nil {
+		t.Fatalf("first send: %v", err)
+	}
+	if err := s.sendMessage("second"); err != nil {
+		t.Fatalf("second send: %v", err)
+	}
+	if len(s.sendBuffer) != 1 || s.sendBuffer[0] != "second" {
+		t.Fatalf("sendBuffer = %v, want [second]", s.sendBuffer)
+	}
+}
+
+func TestSendMessageContextDropOldestOversizedMessageDropped(t *testing.T) {
+	var gotDropped = -1
+	s := newBufferedTestSession(t, SendBufferOptions{
+		MaxBytes:       3,
+		OverflowPolicy: DropOldest,
+		OnOverflow: func(sessionID string, policy OverflowPolicy, dropped int) {
+			gotDropped = dropped
+		},
+	})
+
+	// "way-too-long" alone exceeds MaxBytes, so it must never be
+	// appended: the buffer has nothing to evict and should stay empty,
+	// not silently grow past the configured cap.
+	if err := s.sendMessage("way-too-long"); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if len(s.sendBuffer) != 0 {
+		t.Fatalf("sendBuffer = %v, want empty", s.sendBuffer)
+	}
+	if gotDropped != 0 {
+		t.Fatalf("OnOverflow dropped = %d, want 0", gotDropped)
+	}
+}
+
+func TestSendMessageContextCloseSession(t *testing.T) {
+	s := newBufferedTestSession(t, SendBufferOptions{MaxMessages: 1, OverflowPolicy: CloseSession})
+
+	if err := s.sendMessage("first"); err != nil {
+		t.Fatalf("first send: %v", err)
+	}
+	if err := s.sendMessage("second"); err != ErrSessionNotOpen {
+		t.Fatalf("second send = %v, want ErrSessionNotOpen", err)
+	}
+	if s.GetSessionState() != SessionClosed {
+		t.Fatalf("state = %v, want SessionClosed", s.GetSessionState())
+	}
+}
+
+func TestSessionStats(t *testing.T) {
+	s := newBufferedTestSession(t, SendBufferOptions{})
+
+	if err := s.sendMessage("abc"); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if err := s.sendMessage("de"); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	stats := s.Stats()
+	if stats.BufferedMessages != 2 {
+		t.Fatalf("BufferedMessages = %d, want 2", stats.BufferedMessages)
+	}
+	if stats.BufferedBytes != 5 {
+		t.Fatalf("BufferedBytes = %d, want 5", stats.BufferedBytes)
+	}
+}
@@ -1,9 +1,11 @@
 package sockjs
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -12,6 +14,10 @@ type sessionState uint32
 const (
 	// brand new session, need to send "h" to receiver
 	sessionOpening sessionState = iota
+	// "o" has been sent and an Authenticator is configured; waiting for
+	// the client to complete the challenge/response exchange before
+	// becoming active
+	sessionAuthenticating
 	// active session
 	sessionActive
 	// session being closed, sending "closeFrame" to receivers
@@ -38,6 +44,18 @@ type session struct {
 	recvBuffer *messageBuffer // messages received from client to be consumed by application
 	closeFrame string         // closeFrame to send after session is closed
 
+	sendBufferOptions SendBufferOptions
+	sendBufferNotify  chan struct{} // closed and replaced whenever the send buffer shrinks
+
+	authenticator Authenticator // optional; requires a challenge/response exchange before activation
+	verifier      Verifier      // set once Authenticator.Challenge succeeds, used while sessionAuthenticating
+
+	broker SessionBroker // optional; registered in newSession, released in close()
+
+	ctxValue  atomic.Value       // holds a ctxBox wrapping the current context.Context; read lock-free, see Context/WithValue
+	ctxMu     sync.Mutex         // serializes the read-modify-write in WithValue
+	ctxCancel context.CancelFunc // cancels ctxValue's context once the session closes
+
 	// do not use SockJS framing for raw websocket connections
 	raw bool
 
@@ -64,41 +82,207 @@ type receiver interface {
 }
 
 // Session is a central component that handles receiving and sending frames. It maintains internal state
-func newSession(req *http.Request, sessionID string, sessionTimeoutInterval, heartbeatInterval time.Duration) *session {
+func newSession(req *http.Request, sessionID string, sessionTimeoutInterval, heartbeatInterval time.Duration, sendBufferOptions SendBufferOptions, authenticator Authenticator, broker SessionBroker) *session {
 
 	s := &session{
-		id:  sessionID,
-		req: req,
+		id:                     sessionID,
+		req:                    req,
 		sessionTimeoutInterval: sessionTimeoutInterval,
 		heartbeatInterval:      heartbeatInterval,
 		recvBuffer:             newMessageBuffer(),
 		closeCh:                make(chan struct{}),
+		sendBufferOptions:      sendBufferOptions,
+		sendBufferNotify:       make(chan struct{}),
+		authenticator:          authenticator,
+		broker:                 broker,
 	}
 
+	if broker != nil {
+		// Best-effort: this node created the session, so it owns it
+		// locally regardless of whether the claim could be recorded; a
+		// failure here just means another node might not yet see this
+		// node as the owner until the next successful Claim.
+		broker.Claim(sessionID)
+		go s.relayBrokerInbound(broker)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.ctxValue.Store(ctxBox{ctx})
+	s.ctxCancel = cancel
+
 	s.Lock() // "go test -race" complains if ommited, not sure why as no race can happen here
 	s.timer = time.AfterFunc(sessionTimeoutInterval, s.close)
 	s.Unlock()
 	return s
 }
 
+// relayBrokerInbound runs for the lifetime of a broker-registered session,
+// feeding frames a proxying node published on brokerInboundTopic (i.e.
+// client frames that arrived on a node other than this owner) into the
+// session exactly as if they had arrived over its own attached receiver.
+// It exits once the session closes or the subscription itself fails.
+func (s *session) relayBrokerInbound(broker SessionBroker) {
+	frames, unsubscribe, err := broker.Subscribe(brokerInboundTopic(s.id))
+	if err != nil {
+		return
+	}
+	defer unsubscribe()
+	for {
+		select {
+		case frame, ok := <-frames:
+			if !ok {
+				return
+			}
+			s.accept(string(frame))
+		case <-s.closedNotify():
+			return
+		}
+	}
+}
+
 func (s *session) sendMessage(msg string) error {
-	s.Lock()
-	defer s.Unlock()
+	return s.sendMessageContext(context.Background(), msg)
+}
+
+// lockPollInterval bounds how long lockContext can lag behind the write
+// lock becoming free while polling for it; ctx's cancellation is observed
+// without that delay.
+const lockPollInterval = 2 * time.Millisecond
+
+// lockContext acquires s's write lock, returning ctx.Err() instead of
+// blocking forever if ctx is done first. It polls TryLock rather than
+// parking a dedicated goroutine per call: under sustained write
+// contention combined with frequent caller cancellation, one goroutine per
+// cancelled call would otherwise pile up, each still racing legitimate
+// waiters for the mutex once it finally acquires and immediately releases.
+func (s *session) lockContext(ctx context.Context) error {
+	for {
+		if s.TryLock() {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+func (s *session) sendMessageContext(ctx context.Context, msg string) error {
+	if err := s.lockContext(ctx); err != nil {
+		return err
+	}
 	if s.state > sessionActive {
+		s.Unlock()
 		return ErrSessionNotOpen
 	}
+	for s.sendBufferOptions.limited() && s.bufferFull(msg) && s.sendBufferOptions.OverflowPolicy == Block {
+		wait := s.sendBufferNotify
+		s.Unlock()
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if err := s.lockContext(ctx); err != nil {
+			return err
+		}
+		if s.state > sessionActive {
+			s.Unlock()
+			return ErrSessionNotOpen
+		}
+	}
+	if s.sendBufferOptions.limited() && s.bufferFull(msg) {
+		policy := s.sendBufferOptions.OverflowPolicy
+		onOverflow := s.sendBufferOptions.OnOverflow
+		switch policy {
+		case DropNewest:
+			s.Unlock()
+			if onOverflow != nil {
+				onOverflow(s.id, policy, 0)
+			}
+			return nil
+		case DropOldest:
+			dropped := 0
+			for len(s.sendBuffer) > 0 && s.bufferFull(msg) {
+				s.sendBuffer = s.sendBuffer[1:]
+				dropped++
+			}
+			if s.bufferFull(msg) {
+				// msg alone already exceeds the configured limit: the
+				// buffer is now empty and still over, so there is
+				// nothing left to evict. Drop msg itself rather than
+				// append below and permanently exceed MaxBytes.
+				s.Unlock()
+				if onOverflow != nil {
+					onOverflow(s.id, policy, dropped)
+				}
+				return nil
+			}
+			if onOverflow != nil {
+				onOverflow(s.id, policy, dropped)
+			}
+		case CloseSession:
+			s.Unlock()
+			s.close()
+			if onOverflow != nil {
+				onOverflow(s.id, policy, 0)
+			}
+			return ErrSessionNotOpen
+		}
+	}
 	s.sendBuffer = append(s.sendBuffer, msg)
-	if s.recv != nil && s.recv.canSend() {
+	// Don't flush to recv while authenticating: Verifier owns the wire
+	// until the handshake completes, so application messages stay
+	// buffered (see attachReceiver and verifyAuthResponse).
+	if s.recv != nil && s.recv.canSend() && s.state == SessionActive {
 		s.recv.sendBulk(s.sendBuffer...)
 		s.sendBuffer = nil
 	}
+	s.wakeSenders()
+	broker := s.broker
+	s.Unlock()
+	if broker != nil {
+		// Best-effort fan-out to nodes proxying this session over
+		// routeForeignSession; their long-poll is waiting on
+		// brokerOutboundTopic, not on this node's local recv.
+		broker.Publish(brokerOutboundTopic(s.id), []byte(msg))
+	}
 	return nil
 }
 
+// bufferFull reports whether appending msg to the send buffer would exceed
+// the configured SendBufferOptions limits. Callers must hold s.Lock.
+func (s *session) bufferFull(msg string) bool {
+	opts := s.sendBufferOptions
+	if opts.MaxMessages > 0 && len(s.sendBuffer) >= opts.MaxMessages {
+		return true
+	}
+	if opts.MaxBytes > 0 && s.sendBufferBytes()+len(msg) > opts.MaxBytes {
+		return true
+	}
+	return false
+}
+
+func (s *session) sendBufferBytes() int {
+	n := 0
+	for _, m := range s.sendBuffer {
+		n += len(m)
+	}
+	return n
+}
+
+// wakeSenders notifies goroutines blocked in sendMessageContext that the
+// send buffer has shrunk. Callers must hold s.Lock.
+func (s *session) wakeSenders() {
+	close(s.sendBufferNotify)
+	s.sendBufferNotify = make(chan struct{})
+}
+
 func (s *session) attachReceiver(recv receiver) error {
 	s.Lock()
-	defer s.Unlock()
 	if s.recv != nil {
+		s.Unlock()
 		return errSessionReceiverAttached
 	}
 	s.recv = recv
@@ -114,23 +298,49 @@ func (s *session) attachReceiver(recv receiver) error {
 
 	if s.state == SessionClosing {
 		if !s.raw {
-			s.recv.sendFrame(s.closeFrame)
+			recv.sendFrame(s.closeFrame)
 		}
-		s.recv.close()
+		recv.close()
+		s.Unlock()
 		return nil
 	}
 	if s.state == SessionOpening {
 		if !s.raw {
-			s.recv.sendFrame("o")
+			recv.sendFrame("o")
 		}
-		s.state = SessionActive
+		if s.authenticator != nil {
+			authenticator := s.authenticator
+			req := s.req
+			// Challenge is an arbitrary app callback (e.g. a round trip to
+			// an auth service); run it without holding the lock so it
+			// can't stall every other operation on this session.
+			s.Unlock()
+			challenge, verifier, err := authenticator.Challenge(req)
+			if err != nil {
+				status, reason := authFailureCode(authenticator)
+				s.Close(status, reason)
+				return err
+			}
+			s.Lock()
+			s.verifier = verifier
+			s.state = sessionAuthenticating
+			if !s.raw {
+				recv.sendFrame(authChallengeFrame(challenge))
+			}
+		} else {
+			s.state = SessionActive
+		}
+	}
+	if s.state == SessionActive {
+		recv.sendBulk(s.sendBuffer...)
+		s.sendBuffer = nil
+		s.wakeSenders()
 	}
-	s.recv.sendBulk(s.sendBuffer...)
-	s.sendBuffer = nil
 	s.timer.Stop()
 	if s.heartbeatInterval > 0 {
 		s.timer = time.AfterFunc(s.heartbeatInterval, s.heartbeat)
 	}
+	s.Unlock()
 	return nil
 }
 
@@ -152,7 +362,68 @@ func (s *session) heartbeat() {
 }
 
 func (s *session) accept(messages ...string) error {
-	return s.recvBuffer.push(messages...)
+	// State is re-checked per message, not once for the whole batch: a
+	// single xhr-polling POST can deliver several frames together, and the
+	// message that completes the handshake flips state to SessionActive
+	// partway through — any frames after it in the same batch are
+	// application messages, not further auth responses.
+	for _, msg := range messages {
+		s.Lock()
+		authenticating := s.state == sessionAuthenticating
+		verifier := s.verifier
+		s.Unlock()
+
+		if authenticating {
+			// While authenticating, incoming frames are handshake
+			// responses, not application messages: they never reach
+			// recvBuffer, so Session.Recv never observes them.
+			if err := s.verifyAuthResponse(verifier, []byte(msg)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := s.recvBuffer.push(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyAuthResponse feeds one round of the client's response to the
+// configured Verifier, advancing the session to sessionActive and
+// flushing any buffered application sends once the handshake completes.
+func (s *session) verifyAuthResponse(verifier Verifier, response []byte) error {
+	challenge, done, err := verifier.Verify(response)
+	if err != nil {
+		status, reason := authFailureCode(verifier)
+		s.Close(status, reason)
+		return err
+	}
+
+	s.Lock()
+	if !done {
+		recv := s.recv
+		s.Unlock()
+		if recv != nil {
+			recv.sendFrame(authChallengeFrame(challenge))
+		}
+		return nil
+	}
+
+	s.state = SessionActive
+	recv := s.recv
+	var pending []string
+	if recv != nil && recv.canSend() {
+		pending = s.sendBuffer
+		s.sendBuffer = nil
+		s.wakeSenders()
+	}
+	s.Unlock()
+
+	if len(pending) > 0 {
+		recv.sendBulk(pending...)
+	}
+	return nil
 }
 
 // idempotent operation
@@ -162,6 +433,7 @@ func (s *session) closing() {
 	if s.state < SessionClosing {
 		s.state = SessionClosing
 		s.recvBuffer.close()
+		s.wakeSenders()
 		if s.recv != nil {
 			s.recv.sendFrame(s.closeFrame)
 			s.recv.close()
@@ -173,16 +445,32 @@ func (s *session) closing() {
 func (s *session) close() {
 	s.closing()
 	s.Lock()
-	defer s.Unlock()
-	if s.state < sessionClosed {
-		s.state = sessionClosed
-		s.timer.Stop()
-		close(s.closeCh)
+	if s.state >= sessionClosed {
+		s.Unlock()
+		return
+	}
+	s.state = sessionClosed
+	s.timer.Stop()
+	close(s.closeCh)
+	s.ctxCancel()
+	broker := s.broker
+	s.Unlock()
+
+	// Release is a network round-trip (e.g. a JetStream KV delete); do it
+	// without holding s.Lock so every other session operation doesn't
+	// stall for the duration of that call.
+	if releaser, ok := broker.(Releaser); ok {
+		releaser.Release(s.id)
 	}
 }
 
 func (s *session) closedNotify() <-chan struct{} { return s.closeCh }
 
+// ClosedNotify returns a channel that is closed once the session has fully
+// closed, for callers outside the sockjs package (e.g. sockjs/jsonrpc) that
+// need to cancel outstanding work tied to the session's lifetime.
+func (s *session) ClosedNotify() <-chan struct{} { return s.closedNotify() }
+
 // Conn interface implementation
 func (s *session) Close(status uint32, reason string) error {
 	s.Lock()
@@ -204,6 +492,32 @@ func (s *session) Send(msg string) error {
 	return s.sendMessage(msg)
 }
 
+// SendContext behaves like Send, but returns ctx.Err() if ctx is cancelled
+// before msg can be accepted.
+func (s *session) SendContext(ctx context.Context, msg string) error {
+	return s.sendMessageContext(ctx, msg)
+}
+
+// RecvContext behaves like Recv, but returns ctx.Err() if ctx is cancelled
+// before a message sent by the client becomes available.
+func (s *session) RecvContext(ctx context.Context) (string, error) {
+	return s.recvBuffer.popContext(ctx)
+}
+
+// CloseContext behaves like Close, but returns ctx.Err() if ctx is done
+// before Close returns. The close still goes ahead in the background in
+// that case.
+func (s *session) CloseContext(ctx context.Context, status uint32, reason string) error {
+	done := make(chan error, 1)
+	go func() { done <- s.Close(status, reason) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (s *session) ID() string { return s.id }
 
 func (s *session) GetSessionState() SessionState {
@@ -212,6 +526,45 @@ func (s *session) GetSessionState() SessionState {
 	return s.state
 }
 
+// Stats reports the current depth of the session's outbound send buffer,
+// so operators can observe backpressure configured via SendBufferOptions
+// without waiting for OnOverflow to fire.
+func (s *session) Stats() SessionStats {
+	s.RLock()
+	defer s.RUnlock()
+	return SessionStats{
+		BufferedMessages: len(s.sendBuffer),
+		BufferedBytes:    s.sendBufferBytes(),
+	}
+}
+
 func (s *session) Request() *http.Request {
 	return s.req
 }
+
+// ctxBox wraps a context.Context so ctxValue always stores the same
+// concrete type: successive context.Context implementations returned by
+// context.WithCancel and context.WithValue are different concrete types,
+// and atomic.Value panics if consecutive Store calls disagree on that.
+type ctxBox struct{ ctx context.Context }
+
+// Context returns the session's context.Context, carrying any values
+// attached via WithValue. It is cancelled once the session closes, so
+// downstream code can use select { case <-sess.Context().Done(): } instead
+// of the sockjs-internal closedNotify/ClosedNotify channels.
+func (s *session) Context() context.Context {
+	return s.ctxValue.Load().(ctxBox).ctx
+}
+
+// WithValue attaches val under key to the session's context, for
+// middleware that wants to carry auth principals, tenant IDs, tracing
+// spans or rate-limit tokens alongside the session. It replaces the
+// context returned by Context with a copy carrying the new value, so
+// concurrent callers of Context never block on a lock; concurrent callers
+// of WithValue are serialized to avoid losing an update.
+func (s *session) WithValue(key, val interface{}) {
+	s.ctxMu.Lock()
+	defer s.ctxMu.Unlock()
+	current := s.ctxValue.Load().(ctxBox).ctx
+	s.ctxValue.Store(ctxBox{context.WithValue(current, key, val)})
+}
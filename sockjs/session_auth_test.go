@@ -0,0 +1,115 @@
+package sockjs
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeReceiver is a minimal receiver good enough to drive attachReceiver
+// without a real transport.
+type fakeReceiver struct {
+	mu          sync.Mutex
+	bulk        []string
+	frames      []string
+	done        chan struct{}
+	interrupted chan struct{}
+}
+
+func newFakeReceiver() *fakeReceiver {
+	return &fakeReceiver{done: make(chan struct{}), interrupted: make(chan struct{})}
+}
+
+func (r *fakeReceiver) sendBulk(msgs ...string) {
+	r.mu.Lock()
+	r.bulk = append(r.bulk, msgs...)
+	r.mu.Unlock()
+}
+func (r *fakeReceiver) sendFrame(frame string) {
+	r.mu.Lock()
+	r.frames = append(r.frames, frame)
+	r.mu.Unlock()
+}
+func (r *fakeReceiver) close()                             {}
+func (r *fakeReceiver) canSend() bool                      { return true }
+func (r *fakeReceiver) doneNotify() <-chan struct{}        { return r.done }
+func (r *fakeReceiver) interruptedNotify() <-chan struct{} { return r.interrupted }
+
+// singleRoundVerifier completes the handshake on its first Verify call,
+// regardless of the response, and records how many times it was called.
+type singleRoundVerifier struct {
+	calls int32
+}
+
+func (v *singleRoundVerifier) Verify(response []byte) ([]byte, bool, error) {
+	atomic.AddInt32(&v.calls, 1)
+	return nil, true, nil
+}
+
+type fakeAuthenticator struct {
+	verifier Verifier
+}
+
+func (a *fakeAuthenticator) Challenge(req *http.Request) ([]byte, Verifier, error) {
+	return []byte("challenge"), a.verifier, nil
+}
+
+type erroringVerifier struct{}
+
+func (erroringVerifier) Verify(response []byte) ([]byte, bool, error) {
+	return nil, false, authError{fixedFailureCode{4002, "bad creds"}}
+}
+
+type authError struct{ fixedFailureCode }
+
+func (e authError) Error() string { return e.reason }
+
+func TestAcceptRechecksAuthStatePerMessageInBatch(t *testing.T) {
+	verifier := &singleRoundVerifier{}
+	s := newTestSession(t)
+	s.authenticator = &fakeAuthenticator{verifier: verifier}
+
+	recv := newFakeReceiver()
+	t.Cleanup(func() { close(recv.done) })
+	if err := s.attachReceiver(recv); err != nil {
+		t.Fatalf("attachReceiver: %v", err)
+	}
+	if s.GetSessionState() != sessionAuthenticating {
+		t.Fatalf("state after attach = %v, want sessionAuthenticating", s.GetSessionState())
+	}
+
+	// A single xhr-polling batch carrying the message that completes the
+	// handshake ("auth-ok") followed by an application message ("hello").
+	// Only the first should reach the Verifier; "hello" must be pushed to
+	// recvBuffer, not fed to Verify a second time.
+	if err := s.accept("auth-ok", "hello"); err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	if calls := atomic.LoadInt32(&verifier.calls); calls != 1 {
+		t.Fatalf("Verify called %d times, want 1", calls)
+	}
+	if s.GetSessionState() != SessionActive {
+		t.Fatalf("state after accept = %v, want SessionActive", s.GetSessionState())
+	}
+
+	msg, err := s.RecvContext(context.Background())
+	if err != nil {
+		t.Fatalf("RecvContext: %v", err)
+	}
+	if msg != "hello" {
+		t.Fatalf("RecvContext = %q, want %q", msg, "hello")
+	}
+}
+
+func TestVerifyAuthResponseErrorClosesSession(t *testing.T) {
+	s := newTestSession(t)
+
+	if err := s.verifyAuthResponse(erroringVerifier{}, []byte("bad")); err == nil {
+		t.Fatal("verifyAuthResponse: want an error")
+	}
+	if s.GetSessionState() < SessionClosing {
+		t.Fatalf("state = %v, want at least SessionClosing", s.GetSessionState())
+	}
+}
@@ -0,0 +1,68 @@
+package sockjs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestSession(t *testing.T) *session {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	s := newSession(req, "test-session", time.Minute, 0, SendBufferOptions{}, nil, nil)
+	t.Cleanup(s.close)
+	return s
+}
+
+func TestSendContextCancelledWhileLockContended(t *testing.T) {
+	s := newTestSession(t)
+
+	s.Lock()
+	defer s.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := s.SendContext(ctx, "hello")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("SendContext = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestRecvContextCancelled(t *testing.T) {
+	s := newTestSession(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := s.RecvContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("RecvContext = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestCloseContextSucceeds(t *testing.T) {
+	s := newTestSession(t)
+
+	if err := s.CloseContext(context.Background(), 1000, "bye"); err != nil {
+		t.Fatalf("CloseContext: %v", err)
+	}
+}
+
+func TestSendThenRecvRoundTrip(t *testing.T) {
+	s := newTestSession(t)
+
+	if err := s.accept("hello"); err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	msg, err := s.RecvContext(context.Background())
+	if err != nil {
+		t.Fatalf("RecvContext: %v", err)
+	}
+	if msg != "hello" {
+		t.Fatalf("RecvContext = %q, want %q", msg, "hello")
+	}
+}